@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// ErrMigrationDrift indicates that a previously applied migration no longer
+// matches the checksum recorded for it in schema_migrations, meaning its
+// body was edited after it shipped to this database.
+type ErrMigrationDrift struct {
+	Version         int
+	Stored, Current string
+}
+
+func (e *ErrMigrationDrift) Error() string {
+	return fmt.Sprintf("migration %d has drifted since it was applied: stored checksum %s, current checksum %s",
+		e.Version, e.Stored, e.Current)
+}
+
+// checksum hashes a migration's identifying body. An empty body yields an
+// empty checksum, meaning the migration opts out of drift detection.
+func checksum(body string) string {
+	if body == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+// ensureChecksumColumn adds the checksum column to a schema_migrations table
+// created before checksums existed, leaving prior rows with a NULL checksum
+// (and so exempt from drift detection).
+func ensureChecksumColumn(db *sql.DB, dialect Dialect) error {
+	if _, err := db.Exec("select checksum from schema_migrations where 1 = 0"); err == nil {
+		return nil
+	}
+	_, err := db.Exec(dialect.AddChecksumColumn())
+	return err
+}
+
+// checkDrift compares the checksum recorded for each applied migration
+// against migrations' current Checksum field, returning *ErrMigrationDrift
+// for the first mismatch found. Migrations with no recorded checksum (NULL,
+// from before this package tracked them) or no current Checksum (opted out)
+// are skipped.
+func checkDrift(db *sql.DB, migrations map[int]Migration) error {
+	rows, err := db.Query("select version, checksum from schema_migrations where checksum is not null")
+	if err != nil {
+		return fmt.Errorf("failed to read applied checksums: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var stored string
+		if err := rows.Scan(&version, &stored); err != nil {
+			return err
+		}
+		if stored == "" {
+			continue
+		}
+		migration, ok := migrations[version]
+		if !ok || migration.Checksum == "" {
+			continue
+		}
+		if current := checksum(migration.Checksum); current != stored {
+			return &ErrMigrationDrift{Version: version, Stored: stored, Current: current}
+		}
+	}
+	return rows.Err()
+}
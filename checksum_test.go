@@ -0,0 +1,69 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateDetectsDrift(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: {
+			Up: func(tx *sql.Tx) error {
+				_, err := tx.Exec("create table users (id integer primary key)")
+				return err
+			},
+			Checksum: "create table users (id integer primary key)",
+		},
+	}
+	require.NoError(t, Migrate(db, migrations))
+
+	migrations[1] = Migration{
+		Up:       migrations[1].Up,
+		Checksum: "create table users (id integer primary key, name text)",
+	}
+
+	err = Migrate(db, migrations)
+	require.Error(t, err)
+	var drift *ErrMigrationDrift
+	require.True(t, errors.As(err, &drift))
+	require.Equal(t, 1, drift.Version)
+}
+
+// TestMigrateAddsChecksumColumnToLegacyTable checks that Migrate upgrades a
+// schema_migrations table created before checksums existed. Only the column
+// is added; the existing row keeps a NULL checksum and is not back-filled.
+func TestMigrateAddsChecksumColumnToLegacyTable(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	_, err = db.Exec(`create table schema_migrations (
+		version int primary key,
+		created_at text not null
+	)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`insert into schema_migrations (version, created_at) values (1, '2024-01-01T00:00:00Z')`)
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table users (id integer primary key)")
+			return err
+		}),
+		2: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table posts (id integer primary key)")
+			return err
+		}),
+	}
+	err = Migrate(db, migrations)
+	require.NoError(t, err)
+
+	_, err = db.Exec("select * from posts")
+	require.NoError(t, err)
+}
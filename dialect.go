@@ -0,0 +1,302 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Dialect encapsulates the SQL differences between database backends that
+// Migrate and Rollback need to account for: how to create schema_migrations,
+// how to record an applied version, how to format bind placeholders, and how
+// to recognize that schema_migrations doesn't exist yet.
+type Dialect interface {
+	// CreateSchemaTable returns the DDL used to create schema_migrations.
+	CreateSchemaTable() string
+	// InsertVersion returns the parameterized statement used to record an
+	// applied version, taking (version, created_at, checksum) as its three
+	// parameters.
+	InsertVersion() string
+	// Placeholder returns the bind placeholder for the nth (1-indexed)
+	// parameter of a query.
+	Placeholder(n int) string
+	// IsUndefinedTable reports whether err indicates that schema_migrations
+	// does not exist yet.
+	IsUndefinedTable(err error) bool
+	// AddChecksumColumn returns the DDL that adds the checksum column to a
+	// schema_migrations table created before checksums existed.
+	AddChecksumColumn() string
+}
+
+// AdvisoryLocker is implemented by Dialects that can serialize concurrent
+// Migrate runs against the same database. Dialects that don't implement it
+// cause MigrateWithOptions to reject Options.Lock.
+type AdvisoryLocker interface {
+	// Lock blocks until the lock is acquired or timeout elapses (zero means
+	// wait indefinitely), and returns a function that releases it.
+	Lock(ctx context.Context, db *sql.DB, timeout time.Duration) (unlock func() error, err error)
+}
+
+// migrateLockName identifies this package's advisory lock in the Postgres and
+// MySQL locking strategies below; SQLite uses a lock row instead.
+const migrateLockName = "migrate"
+
+var migrateLockKey = int64(fnv32a(migrateLockName))
+
+func fnv32a(s string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum32()
+}
+
+var dialects = map[string]Dialect{
+	"lib/pq":              PostgresDialect{},
+	"jackc/pgx":           PostgresDialect{},
+	"mattn/go-sqlite3":    SQLiteDialect{},
+	"modernc.org/sqlite":  SQLiteDialect{},
+	"go-sql-driver/mysql": MySQLDialect{},
+	"clickhouse":          ClickHouseDialect{},
+}
+
+// RegisterDialect makes a Dialect available for auto-detection. key is
+// matched as a substring against the import path of db.Driver()'s concrete
+// type, so a driver at github.com/example/foodb would register under a key
+// such as "example/foodb".
+func RegisterDialect(key string, dialect Dialect) {
+	dialects[key] = dialect
+}
+
+// detectDialect identifies db's Dialect from the import path of its
+// database/sql driver.
+func detectDialect(db *sql.DB) (Dialect, error) {
+	pkgPath := driverPackage(db)
+	for key, dialect := range dialects {
+		if strings.Contains(pkgPath, key) {
+			return dialect, nil
+		}
+	}
+	return nil, fmt.Errorf("could not auto-detect a Dialect for driver package %q; pass one explicitly via Options.Dialect", pkgPath)
+}
+
+func driverPackage(db *sql.DB) string {
+	t := reflect.TypeOf(db.Driver())
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.PkgPath()
+}
+
+const createSchemaMigrationsDDL = `create table schema_migrations (
+	version int primary key,
+	created_at text not null,
+	checksum text
+)`
+
+// PostgresDialect is the Dialect for github.com/lib/pq and
+// github.com/jackc/pgx.
+type PostgresDialect struct{}
+
+func (PostgresDialect) CreateSchemaTable() string { return createSchemaMigrationsDDL }
+
+func (PostgresDialect) InsertVersion() string {
+	return "insert into schema_migrations (version, created_at, checksum) values ($1, $2, $3)"
+}
+
+func (PostgresDialect) Placeholder(n int) string { return fmt.Sprintf("$%d", n) }
+
+// IsUndefinedTable checks the SQLSTATE code rather than the free-text
+// message, so that an unrelated error (e.g. an undefined column) is never
+// misclassified as a missing table.
+func (PostgresDialect) IsUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code.Name() == "undefined_table"
+	}
+	// pgx's *pgconn.PgError reports its SQLSTATE code through SQLState.
+	var stateErr interface{ SQLState() string }
+	return errors.As(err, &stateErr) && stateErr.SQLState() == "42P01"
+}
+
+func (PostgresDialect) AddChecksumColumn() string {
+	return "alter table schema_migrations add column checksum text"
+}
+
+// Lock takes a session-level pg_advisory_lock, which requires pinning a
+// single connection for the lock's lifetime.
+func (PostgresDialect) Lock(ctx context.Context, db *sql.DB, timeout time.Duration) (func() error, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lockCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		lockCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if _, err := conn.ExecContext(lockCtx, "select pg_advisory_lock($1)", migrateLockKey); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(ctx, "select pg_advisory_unlock($1)", migrateLockKey)
+		return err
+	}, nil
+}
+
+// SQLiteDialect is the Dialect for github.com/mattn/go-sqlite3 and
+// modernc.org/sqlite.
+type SQLiteDialect struct{}
+
+func (SQLiteDialect) CreateSchemaTable() string { return createSchemaMigrationsDDL }
+
+func (SQLiteDialect) InsertVersion() string {
+	return "insert into schema_migrations (version, created_at, checksum) values (?, ?, ?)"
+}
+
+func (SQLiteDialect) Placeholder(int) string { return "?" }
+
+func (SQLiteDialect) IsUndefinedTable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "no such table: schema_migrations")
+}
+
+func (SQLiteDialect) AddChecksumColumn() string {
+	return "alter table schema_migrations add column checksum text"
+}
+
+// Lock has no session-level advisory-lock primitive to fall back on in
+// SQLite, so it claims a dedicated schema_migrations_lock row inside a BEGIN
+// IMMEDIATE transaction, which SQLite only lets one connection hold at a
+// time. The transaction commits as soon as the row is claimed, so (unlike
+// Postgres/MySQL) the lock isn't tied to a single pinned connection for the
+// run's duration; unlock just deletes the row.
+func (SQLiteDialect) Lock(ctx context.Context, db *sql.DB, timeout time.Duration) (func() error, error) {
+	if _, err := db.ExecContext(ctx, "create table if not exists schema_migrations_lock (id int primary key)"); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := claimSQLiteLockRow(ctx, db)
+		if err == nil {
+			break
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for migration lock: %w", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	return func() error {
+		_, err := db.ExecContext(ctx, "delete from schema_migrations_lock where id = 1")
+		return err
+	}, nil
+}
+
+func claimSQLiteLockRow(ctx context.Context, db *sql.DB) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "begin immediate"); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "insert into schema_migrations_lock (id) values (1)"); err != nil {
+		_, _ = conn.ExecContext(ctx, "rollback")
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "commit")
+	return err
+}
+
+// MySQLDialect is the Dialect for github.com/go-sql-driver/mysql.
+type MySQLDialect struct{}
+
+func (MySQLDialect) CreateSchemaTable() string { return createSchemaMigrationsDDL }
+
+func (MySQLDialect) InsertVersion() string {
+	return "insert into schema_migrations (version, created_at, checksum) values (?, ?, ?)"
+}
+
+func (MySQLDialect) Placeholder(int) string { return "?" }
+
+// IsUndefinedTable matches the literal "Error 1146" that
+// github.com/go-sql-driver/mysql embeds in MySQLError.Error() for
+// ER_NO_SUCH_TABLE, rather than the free-text message, so that an unrelated
+// error (e.g. an undefined column) is never misclassified as a missing table.
+func (MySQLDialect) IsUndefinedTable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "Error 1146")
+}
+
+func (MySQLDialect) AddChecksumColumn() string {
+	return "alter table schema_migrations add column checksum text"
+}
+
+// Lock uses GET_LOCK/RELEASE_LOCK, which are session-scoped and so require
+// pinning a single connection for the lock's lifetime.
+func (MySQLDialect) Lock(ctx context.Context, db *sql.DB, timeout time.Duration) (func() error, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seconds := -1
+	if timeout > 0 {
+		seconds = int(timeout / time.Second)
+	}
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "select get_lock(?, ?)", migrateLockName, seconds).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("timed out waiting for migration lock")
+	}
+
+	return func() error {
+		defer conn.Close()
+		_, err := conn.ExecContext(ctx, "select release_lock(?)", migrateLockName)
+		return err
+	}, nil
+}
+
+// ClickHouseDialect is the Dialect for clickhouse-go.
+type ClickHouseDialect struct{}
+
+func (ClickHouseDialect) CreateSchemaTable() string {
+	return `create table schema_migrations (
+	version Int64,
+	created_at String,
+	checksum String
+) engine = MergeTree() order by version`
+}
+
+func (ClickHouseDialect) InsertVersion() string {
+	return "insert into schema_migrations (version, created_at, checksum) values (?, ?, ?)"
+}
+
+func (ClickHouseDialect) Placeholder(int) string { return "?" }
+
+// IsUndefinedTable matches ClickHouse's UNKNOWN_TABLE exception code ("code:
+// 60") rather than the free-text message, so that an unrelated error (e.g. an
+// unknown column) is never misclassified as a missing table.
+func (ClickHouseDialect) IsUndefinedTable(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "code: 60")
+}
+
+func (ClickHouseDialect) AddChecksumColumn() string {
+	return "alter table schema_migrations add column checksum String"
+}
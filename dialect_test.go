@@ -0,0 +1,36 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectDialect(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	dialect, err := detectDialect(db)
+	require.NoError(t, err)
+	require.Equal(t, SQLiteDialect{}, dialect)
+}
+
+func TestMigrateWithOptionsDialectOverride(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table widgets (id integer primary key)")
+			return err
+		}),
+	}
+
+	err = MigrateWithOptions(db, migrations, Options{Dialect: SQLiteDialect{}})
+	require.NoError(t, err)
+
+	_, err = db.Exec("select * from widgets")
+	require.NoError(t, err)
+}
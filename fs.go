@@ -0,0 +1,70 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+)
+
+var migrationFileRE = regexp.MustCompile(`^(\d+)_.*\.(up|down)\.sql$`)
+
+// MigrationsFromFS reads versioned SQL migration files out of dir within fsys
+// and builds a Migration map suitable for Migrate and Rollback. Files are
+// named like "0001_create_users.up.sql" and, optionally,
+// "0001_create_users.down.sql"; the leading integer is taken as the version
+// and each file's contents are executed verbatim. fsys may be an embed.FS so
+// that migrations can be shipped inside the binary rather than hand-written
+// as a Go map.
+func MigrationsFromFS(fsys fs.FS, dir string) (map[int]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations directory %q: %w", dir, err)
+	}
+
+	migrations := map[int]Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileRE.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse version from %q: %w", entry.Name(), err)
+		}
+		contents, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %q: %w", entry.Name(), err)
+		}
+
+		m := migrations[version]
+		switch match[2] {
+		case "up":
+			m.Up = execSQL(string(contents))
+			m.Checksum = string(contents)
+		case "down":
+			m.Down = execSQL(string(contents))
+		}
+		migrations[version] = m
+	}
+
+	for version, m := range migrations {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migration %d has a down.sql file but no up.sql file", version)
+		}
+	}
+
+	return migrations, nil
+}
+
+func execSQL(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
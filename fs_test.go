@@ -0,0 +1,52 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationsFromFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0001_create_users.up.sql": &fstest.MapFile{
+			Data: []byte("create table users (id integer primary key)"),
+		},
+		"migrations/0001_create_users.down.sql": &fstest.MapFile{
+			Data: []byte("drop table users"),
+		},
+		"migrations/0002_create_posts.up.sql": &fstest.MapFile{
+			Data: []byte("create table posts (id integer primary key)"),
+		},
+	}
+
+	migrations, err := MigrationsFromFS(fsys, "migrations")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	require.NotNil(t, migrations[1].Up)
+	require.NotNil(t, migrations[1].Down)
+	require.NotNil(t, migrations[2].Up)
+	require.Nil(t, migrations[2].Down)
+
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	require.NoError(t, Migrate(db, migrations))
+
+	_, err = db.Exec("select * from users")
+	require.NoError(t, err)
+	_, err = db.Exec("select * from posts")
+	require.NoError(t, err)
+}
+
+func TestMigrationsFromFSMissingUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0001_create_users.down.sql": &fstest.MapFile{
+			Data: []byte("drop table users"),
+		},
+	}
+
+	_, err := MigrationsFromFS(fsys, "migrations")
+	require.Error(t, err)
+}
@@ -0,0 +1,24 @@
+package migrate
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// stdLogger is the default Logger, used when Options.Logger is nil. It
+// writes plain-text lines through the standard log package: the message
+// followed by args as space-separated key=value pairs.
+type stdLogger struct{}
+
+func (stdLogger) Info(msg string, args ...any)  { log.Print(formatLogLine(msg, args)) }
+func (stdLogger) Error(msg string, args ...any) { log.Print(formatLogLine(msg, args)) }
+
+func formatLogLine(msg string, args []any) string {
+	var b strings.Builder
+	b.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Info(msg string, args ...any)  { l.lines = append(l.lines, msg) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.lines = append(l.lines, msg) }
+
+func TestMigrateWithOptionsHooksAndLogger(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	var before, after []int
+	var durations []time.Duration
+	logger := &recordingLogger{}
+
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table users (id integer primary key)")
+			return err
+		}),
+	}
+
+	err = MigrateWithOptions(db, migrations, Options{
+		Logger: logger,
+		BeforeEach: func(version int) {
+			before = append(before, version)
+		},
+		AfterEach: func(version int, duration time.Duration) {
+			after = append(after, version)
+			durations = append(durations, duration)
+		},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1}, before)
+	require.Equal(t, []int{1}, after)
+	require.Len(t, durations, 1)
+	require.Contains(t, logger.lines, "applied migration")
+}
+
+func TestMigrateWithOptionsOnError(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	var failed int
+	logger := &recordingLogger{}
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			return sql.ErrTxDone
+		}),
+	}
+
+	err = MigrateWithOptions(db, migrations, Options{
+		Logger: logger,
+		OnError: func(version int, err error) {
+			failed = version
+		},
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, failed)
+	require.Contains(t, logger.lines, "failed to apply migration")
+}
@@ -1,90 +1,205 @@
 package migrate
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"sort"
 	"time"
-
-	"github.com/lib/pq"
-	"github.com/mattn/go-sqlite3"
-)
-
-var (
-	errMissingSchemaMigration = errors.New("missing schema migrations")
 )
 
-// Migration represents a database migration.
-type Migration func(tx *sql.Tx) error
-
-func isUndefinedTable(err error) (bool, error) {
-	var pqerr = &pq.Error{}
-	var sqliteErr sqlite3.Error
+// Migration represents a database migration. Up applies the migration and is
+// required. Down reverses it and is optional; a migration with a nil Down is
+// irreversible and Rollback will refuse to run past it. Checksum, if set, is
+// hashed and compared against the value recorded when the migration was
+// applied; a mismatch on a later Migrate call means the migration's body was
+// edited after it shipped, and is reported as ErrMigrationDrift. Migrations
+// built by MigrationsFromFS set it to their up.sql contents automatically;
+// other callers may set it to a serialized form of the migration or leave it
+// empty to opt out of drift detection.
+type Migration struct {
+	Up       func(tx *sql.Tx) error
+	Down     func(tx *sql.Tx) error
+	Checksum string
+}
 
-	switch {
-	case errors.As(err, &pqerr):
-		return pqerr.Code.Name() == "undefined_table", nil
-	case errors.As(err, &sqliteErr):
-		return err.Error() == "no such table: schema_migrations", nil
-	default:
-		return false, fmt.Errorf("unsupported driver")
-	}
+// UpOnly builds an irreversible Migration from a bare up function, for
+// callers that do not supply a Down.
+func UpOnly(up func(tx *sql.Tx) error) Migration {
+	return Migration{Up: up}
 }
 
 // Migrate the database through outstanding migrations. Each migration is
-// executed in a separate transaction, in the order of the numeric keys.
+// executed in a separate transaction, in the order of the numeric keys. The
+// Dialect is auto-detected from db; use MigrateWithOptions to override it.
 func Migrate(db *sql.DB, migrations map[int]Migration) error {
-	keys := []int{}
-	for k := range migrations {
-		keys = append(keys, k)
+	return MigrateWithOptions(db, migrations, Options{})
+}
+
+// MigrateWithOptions is Migrate with explicit Options, such as a Dialect
+// override for drivers that RegisterDialect doesn't already know about, or
+// Lock to serialize concurrent runs against the same database.
+func MigrateWithOptions(db *sql.DB, migrations map[int]Migration, opts Options) error {
+	dialect, err := resolveDialect(db, opts)
+	if err != nil {
+		return err
 	}
-	sort.Ints(keys)
 
-	var maxApplied int
-	err := withTx(db, func(tx *sql.Tx) error {
-		err := tx.QueryRow("select coalesce(max(version), -1) from schema_migrations").Scan(&maxApplied)
-		if err != nil && !errors.Is(err, sql.ErrNoRows) {
-			undefined, err2 := isUndefinedTable(err)
-			if err2 != nil {
-				return fmt.Errorf("failed to parse error: %w", err2)
-			}
-			if undefined {
-				return errMissingSchemaMigration
-			}
-			return fmt.Errorf("failed to select max applied migration: %w", err)
+	unlock, err := lockIfRequested(db, dialect, opts)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return migrate(db, migrations, dialect, opts)
+}
+
+// lockIfRequested acquires the advisory lock when opts.Lock is set, returning
+// a function that releases it. Callers should defer the returned function
+// unconditionally; it is a no-op if opts.Lock is false. A failure to release
+// is logged rather than returned, since by the time it happens the migration
+// (or rollback) it guarded has already finished; a caller that returned it
+// anyway would be hard pressed to act on it, but a lock left behind (e.g. a
+// stuck SQLite lock row) silently wedges every future Options.Lock call, so
+// it must not pass unnoticed.
+func lockIfRequested(db *sql.DB, dialect Dialect, opts Options) (func(), error) {
+	if !opts.Lock {
+		return func() {}, nil
+	}
+	locker, ok := dialect.(AdvisoryLocker)
+	if !ok {
+		return nil, fmt.Errorf("dialect %T does not support Options.Lock", dialect)
+	}
+	unlock, err := locker.Lock(context.Background(), db, opts.LockTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	logger := resolveLogger(opts)
+	return func() {
+		if err := unlock(); err != nil {
+			logger.Error("failed to release migration lock", "error", err)
 		}
-		return nil
-	})
+	}, nil
+}
+
+func migrate(db *sql.DB, migrations map[int]Migration, dialect Dialect, opts Options) error {
+	keys := sortedKeys(migrations)
+
+	maxApplied, tableExists, err := appliedMaxVersion(db, dialect)
 	if err != nil {
-		if errors.Is(err, errMissingSchemaMigration) {
-			if err = initializeSchemaMigrations(db); err != nil {
-				return fmt.Errorf("failed to initialize schema migrations: %w", err)
-			}
-			return Migrate(db, migrations)
+		return fmt.Errorf("failed to select max applied migration: %w", err)
+	}
+	if !tableExists {
+		if err := initializeSchemaMigrations(db, dialect); err != nil {
+			return fmt.Errorf("failed to initialize schema migrations: %w", err)
 		}
+		return migrate(db, migrations, dialect, opts)
+	}
+
+	if err := ensureChecksumColumn(db, dialect); err != nil {
+		return fmt.Errorf("failed to add checksum column to schema migrations: %w", err)
+	}
+	if err := checkDrift(db, migrations); err != nil {
 		return err
 	}
 
+	logger := resolveLogger(opts)
+
 	for _, k := range keys {
 		if k <= maxApplied {
 			continue
 		}
+		if opts.BeforeEach != nil {
+			opts.BeforeEach(k)
+		}
+
+		start := time.Now()
 		err := withTx(db, func(tx *sql.Tx) error {
-			err := migrations[k](tx)
+			err := migrations[k].Up(tx)
 			if err != nil {
 				return err
 			}
-			_, err = tx.Exec(`insert into schema_migrations (version, created_at)
-			values ($1, $2)`, k, time.Now().Format(time.RFC3339))
+			sum := checksum(migrations[k].Checksum)
+			_, err = tx.Exec(dialect.InsertVersion(), k, time.Now().Format(time.RFC3339), sql.NullString{String: sum, Valid: sum != ""})
+			return err
+		})
+		duration := time.Since(start)
+		if err != nil {
+			logger.Error("failed to apply migration", "version", k, "error", err)
+			if opts.OnError != nil {
+				opts.OnError(k, err)
+			}
+			return err
+		}
+
+		if opts.AfterEach != nil {
+			opts.AfterEach(k, duration)
+		}
+		logger.Info("applied migration", "version", k, "duration", duration)
+	}
+
+	return nil
+}
+
+// Rollback reverts applied migrations down to, but not including,
+// targetVersion. Migrations are undone in descending order, each inside its
+// own transaction, and their schema_migrations row is deleted once the Down
+// function succeeds. Rollback refuses to roll past a migration that has no
+// Down, returning an error that names the offending version. The Dialect is
+// auto-detected from db; use RollbackWithOptions to override it.
+func Rollback(db *sql.DB, migrations map[int]Migration, targetVersion int) error {
+	return RollbackWithOptions(db, migrations, targetVersion, Options{})
+}
+
+// RollbackWithOptions is Rollback with explicit Options, such as a Dialect
+// override for drivers that RegisterDialect doesn't already know about, a
+// Logger to observe progress, or Lock to serialize against a concurrent
+// Migrate/Rollback the same way MigrateWithOptions does.
+func RollbackWithOptions(db *sql.DB, migrations map[int]Migration, targetVersion int, opts Options) error {
+	dialect, err := resolveDialect(db, opts)
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockIfRequested(db, dialect, opts)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	applied, err := appliedVersionsDesc(db)
+	if err != nil {
+		return fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	deleteVersion := fmt.Sprintf("delete from schema_migrations where version = %s", dialect.Placeholder(1))
+	logger := resolveLogger(opts)
+
+	for _, k := range applied {
+		if k <= targetVersion {
+			break
+		}
+		migration, ok := migrations[k]
+		if !ok {
+			return fmt.Errorf("no migration registered for applied version %d", k)
+		}
+		if migration.Down == nil {
+			return fmt.Errorf("migration %d is irreversible and has no down migration", k)
+		}
+		err := withTx(db, func(tx *sql.Tx) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(deleteVersion, k)
 			if err != nil {
 				return err
 			}
-			log.Printf("Applied migration %d", k)
+			logger.Info("rolled back migration", "version", k)
 			return nil
 		})
 		if err != nil {
+			logger.Error("failed to roll back migration", "version", k, "error", err)
 			return err
 		}
 	}
@@ -92,12 +207,54 @@ func Migrate(db *sql.DB, migrations map[int]Migration) error {
 	return nil
 }
 
-func initializeSchemaMigrations(db *sql.DB) error {
-	if _, err := db.Exec(`
-	create table schema_migrations (
-		version int primary key,
-		created_at text not null
-	)`); err != nil {
+func sortedKeys(migrations map[int]Migration) []int {
+	keys := []int{}
+	for k := range migrations {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// appliedMaxVersion returns the highest applied migration version, or -1 if
+// none have been applied. tableExists is false when schema_migrations
+// doesn't exist yet.
+func appliedMaxVersion(db *sql.DB, dialect Dialect) (version int, tableExists bool, err error) {
+	version, tableExists = -1, true
+	txErr := withTx(db, func(tx *sql.Tx) error {
+		err := tx.QueryRow("select coalesce(max(version), -1) from schema_migrations").Scan(&version)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			if dialect.IsUndefinedTable(err) {
+				tableExists = false
+				return nil
+			}
+			return err
+		}
+		return nil
+	})
+	return version, tableExists, txErr
+}
+
+func appliedVersionsDesc(db *sql.DB) ([]int, error) {
+	rows, err := db.Query("select version from schema_migrations order by version desc")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func initializeSchemaMigrations(db *sql.DB, dialect Dialect) error {
+	if _, err := db.Exec(dialect.CreateSchemaTable()); err != nil {
 		return err
 	}
 	return nil
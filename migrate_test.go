@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"testing"
 
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/require"
 )
 
@@ -14,14 +15,14 @@ func TestMigrate(t *testing.T) {
 
 	t.Run("applies migrations", func(t *testing.T) {
 		migrations := map[int]Migration{
-			1: func(tx *sql.Tx) error {
+			1: UpOnly(func(tx *sql.Tx) error {
 				_, err := tx.Exec("create table users (id integer primary key)")
 				return err
-			},
-			2: func(tx *sql.Tx) error {
+			}),
+			2: UpOnly(func(tx *sql.Tx) error {
 				_, err := tx.Exec("create table posts (id integer primary key)")
 				return err
-			},
+			}),
 		}
 		err := Migrate(db, migrations)
 		require.NoError(t, err)
@@ -35,23 +36,23 @@ func TestMigrate(t *testing.T) {
 
 	t.Run("applies only new migrations", func(t *testing.T) {
 		migrations := map[int]Migration{
-			1: func(tx *sql.Tx) error {
+			1: UpOnly(func(tx *sql.Tx) error {
 				_, err := tx.Exec("create table users (id integer primary key)")
 				return err
-			},
+			}),
 		}
 		err := Migrate(db, migrations)
 		require.NoError(t, err)
 
 		migrations = map[int]Migration{
-			1: func(tx *sql.Tx) error {
+			1: UpOnly(func(tx *sql.Tx) error {
 				_, err := tx.Exec("create table users (id integer primary key)")
 				return err
-			},
-			2: func(tx *sql.Tx) error {
+			}),
+			2: UpOnly(func(tx *sql.Tx) error {
 				_, err := tx.Exec("create table posts (id integer primary key)")
 				return err
-			},
+			}),
 		}
 		err = Migrate(db, migrations)
 		require.NoError(t, err)
@@ -59,20 +60,20 @@ func TestMigrate(t *testing.T) {
 
 	t.Run("rolls back to last successful migration", func(t *testing.T) {
 		migrations := map[int]Migration{
-			1: func(tx *sql.Tx) error {
+			1: UpOnly(func(tx *sql.Tx) error {
 				_, err := tx.Exec("create table users (id integer primary key)")
 				return err
-			},
-			2: func(tx *sql.Tx) error {
+			}),
+			2: UpOnly(func(tx *sql.Tx) error {
 				_, err := tx.Exec("create table posts (id integer primary key)")
 				return err
-			},
-			3: func(tx *sql.Tx) error {
+			}),
+			3: UpOnly(func(tx *sql.Tx) error {
 				return fmt.Errorf("failed to apply migration 3")
-			},
-			4: func(tx *sql.Tx) error {
+			}),
+			4: UpOnly(func(tx *sql.Tx) error {
 				return fmt.Errorf("failed to apply migration 4")
-			},
+			}),
 		}
 		err := Migrate(db, migrations)
 		require.Error(t, err)
@@ -85,3 +86,82 @@ func TestMigrate(t *testing.T) {
 	})
 
 }
+
+func TestRollback(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: {
+			Up: func(tx *sql.Tx) error {
+				_, err := tx.Exec("create table users (id integer primary key)")
+				return err
+			},
+			Down: func(tx *sql.Tx) error {
+				_, err := tx.Exec("drop table users")
+				return err
+			},
+		},
+		2: {
+			Up: func(tx *sql.Tx) error {
+				_, err := tx.Exec("create table posts (id integer primary key)")
+				return err
+			},
+			Down: func(tx *sql.Tx) error {
+				_, err := tx.Exec("drop table posts")
+				return err
+			},
+		},
+	}
+	require.NoError(t, Migrate(db, migrations))
+
+	t.Run("rolls back to target version", func(t *testing.T) {
+		err := Rollback(db, migrations, 1)
+		require.NoError(t, err)
+
+		_, err = db.Exec("select * from posts")
+		require.Error(t, err)
+
+		_, err = db.Exec("select * from users")
+		require.NoError(t, err)
+	})
+
+	t.Run("refuses to roll past an irreversible migration", func(t *testing.T) {
+		irreversible := map[int]Migration{
+			1: UpOnly(func(tx *sql.Tx) error {
+				_, err := tx.Exec("create table users (id integer primary key)")
+				return err
+			}),
+		}
+		err := Rollback(db, irreversible, 0)
+		require.Error(t, err)
+	})
+}
+
+func TestRollbackWithOptions(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: {
+			Up: func(tx *sql.Tx) error {
+				_, err := tx.Exec("create table users (id integer primary key)")
+				return err
+			},
+			Down: func(tx *sql.Tx) error {
+				_, err := tx.Exec("drop table users")
+				return err
+			},
+		},
+	}
+	require.NoError(t, MigrateWithOptions(db, migrations, Options{Dialect: SQLiteDialect{}}))
+
+	logger := &recordingLogger{}
+	err = RollbackWithOptions(db, migrations, 0, Options{Dialect: SQLiteDialect{}, Logger: logger})
+	require.NoError(t, err)
+
+	_, err = db.Exec("select * from users")
+	require.Error(t, err)
+
+	require.Contains(t, logger.lines, "rolled back migration")
+}
@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Options configures MigrateWithOptions. The zero value auto-detects the
+// Dialect from the *sql.DB passed to Migrate, applies no locking, and logs
+// through the standard log package.
+type Options struct {
+	// Dialect overrides auto-detection of the database backend. Set this
+	// when using a driver that RegisterDialect does not already know
+	// about, or to force a specific Dialect.
+	Dialect Dialect
+
+	// Lock serializes concurrent Migrate/MigrateWithOptions calls against
+	// the same database using an advisory lock, so that two instances
+	// starting at once can't race on schema_migrations. The Dialect in use
+	// must implement AdvisoryLocker.
+	Lock bool
+
+	// LockTimeout bounds how long Lock waits to acquire the advisory lock
+	// before giving up. Zero means wait indefinitely.
+	LockTimeout time.Duration
+
+	// Logger receives a line for each applied migration. *slog.Logger
+	// satisfies this interface. A nil Logger logs through the standard log
+	// package.
+	Logger Logger
+
+	// BeforeEach, if set, runs immediately before each migration's
+	// transaction is opened.
+	BeforeEach func(version int)
+
+	// AfterEach, if set, runs after a migration's transaction commits
+	// successfully, with the time taken to apply it.
+	AfterEach func(version int, duration time.Duration)
+
+	// OnError, if set, runs when a migration's transaction fails, before
+	// Migrate returns the error.
+	OnError func(version int, err error)
+}
+
+// Logger is the structured logging interface Migrate reports progress
+// through. *slog.Logger satisfies it.
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+func resolveDialect(db *sql.DB, opts Options) (Dialect, error) {
+	if opts.Dialect != nil {
+		return opts.Dialect, nil
+	}
+	return detectDialect(db)
+}
+
+func resolveLogger(opts Options) Logger {
+	if opts.Logger != nil {
+		return opts.Logger
+	}
+	return stdLogger{}
+}
@@ -0,0 +1,88 @@
+package migrate
+
+import (
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateWithOptionsLock(t *testing.T) {
+	// Options.Lock claims the SQLite lock row through a connection of its
+	// own, so with an in-memory sqlite3 database (where each connection is
+	// its own database) that connection may not see the other connections'
+	// writes; use a file.
+	dsn := filepath.Join(t.TempDir(), "migrate.db")
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table users (id integer primary key)")
+			return err
+		}),
+	}
+
+	err = MigrateWithOptions(db, migrations, Options{Lock: true})
+	require.NoError(t, err)
+
+	_, err = db.Exec("select * from users")
+	require.NoError(t, err)
+}
+
+func TestMigrateWithOptionsLockSerializesConcurrentCallers(t *testing.T) {
+	// Two callers race MigrateWithOptions against the same database. Without
+	// Options.Lock both could observe no applied migrations and run Up
+	// concurrently; the sleep inside Up widens that window so an unserialized
+	// run would reliably overlap.
+	dsn := filepath.Join(t.TempDir(), "migrate.db")
+	db, err := sql.Open("sqlite3", dsn)
+	require.NoError(t, err)
+
+	var inFlight int32
+	var overlapped int32
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			if atomic.AddInt32(&inFlight, 1) > 1 {
+				atomic.StoreInt32(&overlapped, 1)
+			}
+			time.Sleep(20 * time.Millisecond)
+			atomic.AddInt32(&inFlight, -1)
+			_, err := tx.Exec("create table users (id integer primary key)")
+			return err
+		}),
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- MigrateWithOptions(db, migrations, Options{Lock: true, LockTimeout: 5 * time.Second})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.Zero(t, atomic.LoadInt32(&overlapped), "Options.Lock should have serialized the two callers")
+
+	_, err = db.Exec("select * from users")
+	require.NoError(t, err)
+}
+
+func TestMigrateWithOptionsLockUnsupportedDialect(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	err = MigrateWithOptions(db, nil, Options{Dialect: ClickHouseDialect{}, Lock: true})
+	require.Error(t, err)
+}
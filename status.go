@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// AppliedMigration is a row read back from schema_migrations by Status.
+type AppliedMigration struct {
+	Version   int
+	AppliedAt time.Time
+}
+
+// Pending returns the migration versions that Migrate would apply if called
+// now, in ascending order.
+func Pending(db *sql.DB, migrations map[int]Migration) ([]int, error) {
+	dialect, err := resolveDialect(db, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	maxApplied, _, err := appliedMaxVersion(db, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine applied migrations: %w", err)
+	}
+
+	var pending []int
+	for _, k := range sortedKeys(migrations) {
+		if k > maxApplied {
+			pending = append(pending, k)
+		}
+	}
+	return pending, nil
+}
+
+// DryRun applies all pending migrations' Up functions in order inside a
+// single transaction, then rolls it back, leaving db unmodified. Running
+// them in one transaction (rather than one per migration) means a migration
+// sees the schema its predecessors would have produced, so e.g. an `alter
+// table` against a table a prior pending migration creates works the same
+// way it would under Migrate. Each migration runs inside its own savepoint
+// so a failure is attributed to its version. DryRun is useful in CI for
+// validating a PR's migrations against a production snapshot without
+// mutating it.
+func DryRun(db *sql.DB, migrations map[int]Migration) error {
+	pending, err := Pending(db, migrations)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, k := range pending {
+		savepoint := fmt.Sprintf("dry_run_%d", k)
+		if _, err := tx.Exec("savepoint " + savepoint); err != nil {
+			return fmt.Errorf("dry run failed to set savepoint for migration %d: %w", k, err)
+		}
+		if err := migrations[k].Up(tx); err != nil {
+			return fmt.Errorf("dry run failed on migration %d: %w", k, err)
+		}
+		if _, err := tx.Exec("release savepoint " + savepoint); err != nil {
+			return fmt.Errorf("dry run failed to release savepoint for migration %d: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// Status returns the migrations that have been applied to db, in ascending
+// version order.
+func Status(db *sql.DB) ([]AppliedMigration, error) {
+	dialect, err := resolveDialect(db, Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query("select version, created_at from schema_migrations order by version asc")
+	if err != nil {
+		if dialect.IsUndefinedTable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var version int
+		var createdAt string
+		if err := rows.Scan(&version, &createdAt); err != nil {
+			return nil, err
+		}
+		appliedAt, err := time.Parse(time.RFC3339, createdAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse created_at for migration %d: %w", version, err)
+		}
+		applied = append(applied, AppliedMigration{Version: version, AppliedAt: appliedAt})
+	}
+	return applied, rows.Err()
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingAndStatus(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table users (id integer primary key)")
+			return err
+		}),
+		2: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table posts (id integer primary key)")
+			return err
+		}),
+	}
+
+	pending, err := Pending(db, migrations)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, pending)
+
+	status, err := Status(db)
+	require.NoError(t, err)
+	require.Empty(t, status)
+
+	require.NoError(t, Migrate(db, map[int]Migration{1: migrations[1]}))
+
+	pending, err = Pending(db, migrations)
+	require.NoError(t, err)
+	require.Equal(t, []int{2}, pending)
+
+	status, err = Status(db)
+	require.NoError(t, err)
+	require.Len(t, status, 1)
+	require.Equal(t, 1, status[0].Version)
+	require.False(t, status[0].AppliedAt.IsZero())
+}
+
+func TestDryRun(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table users (id integer primary key)")
+			return err
+		}),
+	}
+
+	err = DryRun(db, migrations)
+	require.NoError(t, err)
+
+	status, err := Status(db)
+	require.NoError(t, err)
+	require.Empty(t, status)
+
+	_, err = db.Exec("select * from users")
+	require.Error(t, err)
+}
+
+func TestDryRunDependentMigrations(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("create table users (id integer primary key)")
+			return err
+		}),
+		2: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("alter table users add column name text")
+			return err
+		}),
+	}
+
+	err = DryRun(db, migrations)
+	require.NoError(t, err)
+
+	_, err = db.Exec("select * from users")
+	require.Error(t, err)
+}
+
+func TestDryRunFailure(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+
+	migrations := map[int]Migration{
+		1: UpOnly(func(tx *sql.Tx) error {
+			_, err := tx.Exec("not valid sql")
+			return err
+		}),
+	}
+
+	err = DryRun(db, migrations)
+	require.Error(t, err)
+}